@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/apprenda/kismatic/pkg/store"
+)
+
+// provisionPhase is the built-in PhaseProvision implementation: it stands up
+// a cluster's infrastructure via a Provisioner built from a
+// ProvisionerCreator.
+type provisionPhase struct {
+	newProvisioner ProvisionerCreator
+}
+
+func (p *provisionPhase) Name() PhaseName { return PhaseProvision }
+
+func (p *provisionPhase) Run(ctx *PhaseContext, spec store.ClusterSpec, status *store.ClusterStatus) error {
+	provisioner, err := p.newProvisioner(ctx.ClusterKey.String(), ctx.ClusterAssetsDir, ctx.LogWriter)
+	if err != nil {
+		return fmt.Errorf("error creating provisioner: %v", err)
+	}
+	if err := provisioner.Provision(spec); err != nil {
+		return fmt.Errorf("error provisioning cluster: %v", err)
+	}
+	return nil
+}
+
+// installPhase is the built-in PhaseInstall implementation: it runs the
+// installer against already-provisioned infrastructure via an Executor
+// created once for this cluster.
+type installPhase struct {
+	executor Executor
+}
+
+func (p *installPhase) Name() PhaseName { return PhaseInstall }
+
+func (p *installPhase) Run(ctx *PhaseContext, spec store.ClusterSpec, status *store.ClusterStatus) error {
+	if err := p.executor.Execute(spec); err != nil {
+		return fmt.Errorf("error executing installer: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/store"
+)
+
+// Executor drives the installation/upgrade of a single cluster against
+// infrastructure that has already been provisioned.
+type Executor interface {
+	Execute(spec store.ClusterSpec) error
+}
+
+// ExecutorCreator builds an Executor scoped to a single cluster. logOutput
+// receives everything the executor logs while it runs.
+type ExecutorCreator func(clusterName string, assetsDir string, logOutput io.Writer) (Executor, error)
+
+// Provisioner stands up and tears down the infrastructure a cluster's nodes
+// run on.
+type Provisioner interface {
+	Provision(spec store.ClusterSpec) error
+	Destroy(spec store.ClusterSpec) error
+}
+
+// ProvisionerCreator builds a Provisioner scoped to a single cluster.
+type ProvisionerCreator func(clusterName string, assetsDir string, logOutput io.Writer) (Provisioner, error)
+
+// AssetsDir is the root directory under which per-cluster assets (certs,
+// kubeconfigs, logs, ...) are stored.
+type AssetsDir string
+
+// ForCluster returns the assets directory for the named cluster within
+// namespace. Clusters with the same name in different namespaces therefore
+// get independent assets dirs.
+func (a AssetsDir) ForCluster(namespace, name string) string {
+	return filepath.Join(string(a), namespace, name)
+}
+
+// clusterController (cc) owns the lifecycle of a single cluster. A
+// multiClusterController worker calls reconcile whenever the cluster is
+// popped off the work queue, and runs health checks against it on its own
+// cadence in between.
+type clusterController struct {
+	log              *log.Logger
+	clusterKey       ClusterKey
+	clusterAssetsDir string
+	logFile          *os.File
+	ringLog          *RingLog
+	logWriter        io.Writer // logFile and ringLog, combined
+	provider         ClusterProvider
+	phases           *PhaseRegistry
+
+	// healthCheckFreq and probes drive the health-check subsystem. A zero
+	// healthCheckFreq or empty probes disables health checking.
+	healthCheckFreq time.Duration
+	probes          []Probe
+
+	// specMu guards clusterSpec, which a worker goroutine writes on every
+	// reconcile and the health-check goroutine reads concurrently on its own
+	// ticker.
+	specMu      sync.Mutex
+	clusterSpec store.ClusterSpec
+}
+
+// setSpec updates the cluster spec used by the next reconcile and the
+// health-check goroutine.
+func (cc *clusterController) setSpec(spec store.ClusterSpec) {
+	cc.specMu.Lock()
+	defer cc.specMu.Unlock()
+	cc.clusterSpec = spec
+}
+
+// spec returns the cluster spec most recently set with setSpec.
+func (cc *clusterController) spec() store.ClusterSpec {
+	cc.specMu.Lock()
+	defer cc.specMu.Unlock()
+	return cc.clusterSpec
+}
+
+// reconcile drives the cluster towards its desired spec by running it
+// through cc.phases in order: by default that's provisioning the underlying
+// infrastructure and then running the installer against it, but operators
+// can register additional phases (placement, validation, network-policy or
+// tenant hooks, ...) around those two. A phase that returns an error stops
+// the pipeline there; conditions any phase set on status are persisted
+// either way.
+func (cc *clusterController) reconcile() error {
+	ctx := context.Background()
+	cluster, err := cc.provider.Get(ctx, cc.clusterKey)
+	if err != nil {
+		return fmt.Errorf("error loading cluster for reconcile: %v", err)
+	}
+	phaseCtx := newPhaseContext(ctx, cc.clusterKey, cc.clusterAssetsDir, cc.logWriter)
+	spec := cc.spec()
+	var reconcileErr error
+	for _, phase := range cc.phases.Phases() {
+		if reconcileErr = phase.Run(phaseCtx, spec, &cluster.Status); reconcileErr != nil {
+			reconcileErr = fmt.Errorf("phase %q failed: %v", phase.Name(), reconcileErr)
+			break
+		}
+	}
+	if err := cc.provider.UpdateStatus(ctx, cc.clusterKey, cluster.Status); err != nil {
+		cc.log.Printf("cluster %q: failed to persist status after reconcile: %v", cc.clusterKey, err)
+	}
+	return reconcileErr
+}
@@ -0,0 +1,32 @@
+package controller
+
+import "strings"
+
+// defaultNamespace is assumed for clusters recorded under legacy,
+// unnamespaced store keys, so that existing single-namespace deployments
+// keep working unchanged after upgrading.
+const defaultNamespace = "default"
+
+// ClusterKey uniquely identifies a cluster across tenants/namespaces. Two
+// clusters with the same Name but different Namespace are entirely
+// independent: separate workers, assets dirs, and log files.
+type ClusterKey struct {
+	Namespace string
+	Name      string
+}
+
+// String renders the key as "namespace/name", the form used for store keys,
+// assets-dir paths, and log-file paths.
+func (k ClusterKey) String() string {
+	return k.Namespace + "/" + k.Name
+}
+
+// parseClusterKey parses a store key of the form "namespace/name", falling
+// back to defaultNamespace for legacy keys that predate namespacing and
+// don't contain one.
+func parseClusterKey(storeKey string) ClusterKey {
+	if i := strings.IndexByte(storeKey, '/'); i >= 0 {
+		return ClusterKey{Namespace: storeKey[:i], Name: storeKey[i+1:]}
+	}
+	return ClusterKey{Namespace: defaultNamespace, Name: storeKey}
+}
@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/store"
+)
+
+type fakeClusterStore struct{}
+
+func (fakeClusterStore) Get(key string) (store.Cluster, error)       { return store.Cluster{}, nil }
+func (fakeClusterStore) Put(key string, cluster store.Cluster) error { return nil }
+func (fakeClusterStore) GetAll() (map[string]store.Cluster, error)   { return nil, nil }
+func (fakeClusterStore) Watch(ctx context.Context, fromRevision int64) <-chan store.WatchResponse {
+	ch := make(chan store.WatchResponse)
+	go func() {
+		<-ctx.Done()
+		close(ch)
+	}()
+	return ch
+}
+
+type fakeExecutor struct{}
+
+func (fakeExecutor) Execute(spec store.ClusterSpec) error { return nil }
+
+type fakeProvisioner struct{}
+
+func (fakeProvisioner) Provision(spec store.ClusterSpec) error { return nil }
+func (fakeProvisioner) Destroy(spec store.ClusterSpec) error   { return nil }
+
+func newTestMultiClusterController(assetsRoot string) *multiClusterController {
+	newExecutor := func(clusterName, assetsDir string, logOutput io.Writer) (Executor, error) {
+		return fakeExecutor{}, nil
+	}
+	newProvisioner := func(clusterName, assetsDir string, logOutput io.Writer) (Provisioner, error) {
+		return fakeProvisioner{}, nil
+	}
+	return New(
+		AssetsDir(assetsRoot),
+		log.New(os.Stderr, "", 0),
+		newExecutor,
+		newProvisioner,
+		fakeClusterStore{},
+		time.Minute,
+		WithHealthChecks(0, nil), // disable health-check goroutines for the test
+	)
+}
+
+// TestSameNamedClustersInDifferentNamespacesGetIndependentWorkersAssetsAndLogs
+// drives multiClusterController with two clusters both named "prod", one in
+// namespace teamA and one in teamB, and asserts they end up as two distinct
+// managedCluster entries with independent assets dirs and log files.
+func TestSameNamedClustersInDifferentNamespacesGetIndependentWorkersAssetsAndLogs(t *testing.T) {
+	assetsRoot := t.TempDir()
+	mcc := newTestMultiClusterController(assetsRoot)
+	workerLog := log.New(os.Stderr, "", 0)
+
+	teamA := ClusterKey{Namespace: "teamA", Name: "prod"}
+	teamB := ClusterKey{Namespace: "teamB", Name: "prod"}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	managedA, err := mcc.getOrCreateManagedCluster(ctx, teamA, store.Cluster{}, workerLog)
+	if err != nil {
+		t.Fatalf("getOrCreateManagedCluster(%v) failed: %v", teamA, err)
+	}
+	managedB, err := mcc.getOrCreateManagedCluster(ctx, teamB, store.Cluster{}, workerLog)
+	if err != nil {
+		t.Fatalf("getOrCreateManagedCluster(%v) failed: %v", teamB, err)
+	}
+	defer mcc.removeClusterController(teamA)
+	defer mcc.removeClusterController(teamB)
+
+	if managedA == managedB || managedA.cc == managedB.cc {
+		t.Fatal("both namespaces were served by the same managedCluster/clusterController")
+	}
+
+	wantDirA := filepath.Join(assetsRoot, "teamA", "prod")
+	wantDirB := filepath.Join(assetsRoot, "teamB", "prod")
+	if managedA.cc.clusterAssetsDir != wantDirA {
+		t.Errorf("teamA/prod clusterAssetsDir = %q, want %q", managedA.cc.clusterAssetsDir, wantDirA)
+	}
+	if managedB.cc.clusterAssetsDir != wantDirB {
+		t.Errorf("teamB/prod clusterAssetsDir = %q, want %q", managedB.cc.clusterAssetsDir, wantDirB)
+	}
+	if managedA.cc.clusterAssetsDir == managedB.cc.clusterAssetsDir {
+		t.Fatalf("both clusters got the same assets dir: %q", managedA.cc.clusterAssetsDir)
+	}
+	if managedA.cc.logFile.Name() == managedB.cc.logFile.Name() {
+		t.Fatalf("both clusters got the same log file: %q", managedA.cc.logFile.Name())
+	}
+	for _, dir := range []string{managedA.cc.clusterAssetsDir, managedB.cc.clusterAssetsDir} {
+		if _, err := os.Stat(dir); err != nil {
+			t.Errorf("assets dir %q was not created: %v", dir, err)
+		}
+	}
+
+	mcc.mu.Lock()
+	n := len(mcc.clusterControllers)
+	mcc.mu.Unlock()
+	if n != 2 {
+		t.Fatalf("clusterControllers has %d entries, want 2 (one per namespace)", n)
+	}
+}
+
+func TestParseClusterKeyFallsBackToDefaultNamespace(t *testing.T) {
+	key := parseClusterKey("legacy-cluster")
+	want := ClusterKey{Namespace: defaultNamespace, Name: "legacy-cluster"}
+	if key != want {
+		t.Fatalf("parseClusterKey(%q) = %v, want %v", "legacy-cluster", key, want)
+	}
+}
+
+func TestParseClusterKeyWithNamespace(t *testing.T) {
+	key := parseClusterKey("teamA/prod")
+	want := ClusterKey{Namespace: "teamA", Name: "prod"}
+	if key != want {
+		t.Fatalf("parseClusterKey(%q) = %v, want %v", "teamA/prod", key, want)
+	}
+}
@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	kismaticv1alpha1 "github.com/apprenda/kismatic/pkg/apis/kismatic/v1alpha1"
+	kismaticclientset "github.com/apprenda/kismatic/pkg/client/clientset/versioned"
+	kismaticinformers "github.com/apprenda/kismatic/pkg/client/informers/externalversions"
+	"github.com/apprenda/kismatic/pkg/store"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// crdClusterProvider is a ClusterProvider backed by the Cluster custom
+// resource, fed by a shared informer so this controller and any other
+// controller-runtime-style manager watching the same CRD share one watch
+// against the API server. It is scoped to a single namespace; run one per
+// namespace (fanned in with NewCompositeClusterProvider) to watch several.
+type crdClusterProvider struct {
+	client    kismaticclientset.Interface
+	informer  cache.SharedIndexInformer
+	namespace string
+}
+
+// NewCRDClusterProvider builds a ClusterProvider backed by Cluster custom
+// resources in namespace, using factory's shared informer. The informer is
+// started the first time Watch is called.
+func NewCRDClusterProvider(client kismaticclientset.Interface, factory kismaticinformers.SharedInformerFactory, namespace string) ClusterProvider {
+	informer := factory.Kismatic().V1alpha1().Clusters().Informer()
+	return &crdClusterProvider{client: client, informer: informer, namespace: namespace}
+}
+
+func (p *crdClusterProvider) List(ctx context.Context) (map[ClusterKey]store.Cluster, error) {
+	clusters := make(map[ClusterKey]store.Cluster)
+	for _, obj := range p.informer.GetStore().List() {
+		cr, ok := obj.(*kismaticv1alpha1.Cluster)
+		if !ok || cr.Namespace != p.namespace {
+			continue
+		}
+		clusters[ClusterKey{Namespace: cr.Namespace, Name: cr.Name}] = toStoreCluster(cr)
+	}
+	return clusters, nil
+}
+
+func (p *crdClusterProvider) Get(ctx context.Context, key ClusterKey) (store.Cluster, error) {
+	if key.Namespace != p.namespace {
+		return store.Cluster{}, fmt.Errorf("cluster %q not found", key)
+	}
+	obj, found, err := p.informer.GetStore().GetByKey(key.String())
+	if err != nil {
+		return store.Cluster{}, fmt.Errorf("error looking up cluster %q: %v", key, err)
+	}
+	if !found {
+		return store.Cluster{}, fmt.Errorf("cluster %q not found", key)
+	}
+	return toStoreCluster(obj.(*kismaticv1alpha1.Cluster)), nil
+}
+
+// UpdateStatus writes status back to the Cluster custom resource's status
+// subresource via the API server, so that health checks and reconciles for
+// CRD-defined clusters are visible to any other controller-runtime-style
+// manager watching the same resource.
+func (p *crdClusterProvider) UpdateStatus(ctx context.Context, key ClusterKey, status store.ClusterStatus) error {
+	if key.Namespace != p.namespace {
+		return fmt.Errorf("cluster %q not found", key)
+	}
+	cr, err := p.client.KismaticV1alpha1().Clusters(p.namespace).Get(ctx, key.Name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("error loading cluster %q to update status: %v", key, err)
+	}
+	cr.Status = status
+	_, err = p.client.KismaticV1alpha1().Clusters(p.namespace).UpdateStatus(ctx, cr, metav1.UpdateOptions{})
+	return err
+}
+
+func (p *crdClusterProvider) Watch(ctx context.Context) <-chan ClusterEvent {
+	events := make(chan ClusterEvent)
+	send := func(eventType ClusterEventType, obj interface{}) {
+		cr, ok := obj.(*kismaticv1alpha1.Cluster)
+		if !ok || cr.Namespace != p.namespace {
+			return
+		}
+		key := ClusterKey{Namespace: cr.Namespace, Name: cr.Name}
+		event := ClusterEvent{Type: eventType, ClusterKey: key, Cluster: toStoreCluster(cr)}
+		select {
+		case events <- event:
+		case <-ctx.Done():
+		}
+	}
+	p.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { send(ClusterAdded, obj) },
+		UpdateFunc: func(old, new interface{}) { send(ClusterUpdated, new) },
+		DeleteFunc: func(obj interface{}) { send(ClusterDeleted, obj) },
+	})
+	go p.informer.Run(ctx.Done())
+	go func() {
+		<-ctx.Done()
+		close(events)
+	}()
+	return events
+}
+
+// toStoreCluster converts a Cluster custom resource into the store.Cluster
+// shape the rest of the controller package works with, so that callers of
+// ClusterProvider don't need to care whether a cluster came from the CRD or
+// the bbolt store.
+func toStoreCluster(cr *kismaticv1alpha1.Cluster) store.Cluster {
+	return store.Cluster{
+		Spec:   cr.Spec,
+		Status: cr.Status,
+	}
+}
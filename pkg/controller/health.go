@@ -0,0 +1,213 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/store"
+)
+
+// defaultHealthCheckFreq is the cadence at which a clusterController probes
+// its cluster's health when the multiClusterController isn't given an
+// explicit WithHealthChecks option.
+const defaultHealthCheckFreq = 30 * time.Second
+
+// Well-known condition types reported on store.Cluster.Status. Provisioners
+// that register their own probes are free to report additional, custom
+// condition types alongside these.
+const (
+	ConditionReady        store.ConditionType = "Ready"
+	ConditionAPIReachable store.ConditionType = "APIServerReachable"
+	ConditionNodesHealthy store.ConditionType = "NodesHealthy"
+	ConditionEtcdHealthy  store.ConditionType = "EtcdHealthy"
+)
+
+// Probe checks one aspect of a cluster's health and reports the resulting
+// condition. Custom probes (network policy checks, tenant-specific add-on
+// checks, ...) can be supplied via multiClusterController's WithHealthChecks
+// option; the probes given there run against every cluster, whatever its
+// provisioner type.
+type Probe interface {
+	Check(ctx context.Context, spec store.ClusterSpec) store.Condition
+}
+
+// ProbeFunc adapts a plain function to a Probe.
+type ProbeFunc func(ctx context.Context, spec store.ClusterSpec) store.Condition
+
+// Check implements Probe.
+func (f ProbeFunc) Check(ctx context.Context, spec store.ClusterSpec) store.Condition {
+	return f(ctx, spec)
+}
+
+// defaultProbes returns the probes every clusterController runs unless the
+// multiClusterController was constructed with a custom set.
+func defaultProbes() []Probe {
+	return []Probe{
+		ProbeFunc(probeAPIServerReachable),
+		ProbeFunc(probeNodesReady),
+		ProbeFunc(probeEtcdQuorum),
+	}
+}
+
+func probeAPIServerReachable(ctx context.Context, spec store.ClusterSpec) store.Condition {
+	now := time.Now()
+	if err := dialAPIServer(ctx, spec); err != nil {
+		return store.Condition{
+			Type:               ConditionAPIReachable,
+			Status:             store.ConditionFalse,
+			LastTransitionTime: now,
+			Reason:             "APIServerUnreachable",
+			Message:            err.Error(),
+		}
+	}
+	return store.Condition{
+		Type:               ConditionAPIReachable,
+		Status:             store.ConditionTrue,
+		LastTransitionTime: now,
+	}
+}
+
+func probeNodesReady(ctx context.Context, spec store.ClusterSpec) store.Condition {
+	now := time.Now()
+	notReady, err := nodesNotReady(ctx, spec)
+	if err != nil {
+		return store.Condition{
+			Type:               ConditionNodesHealthy,
+			Status:             store.ConditionUnknown,
+			LastTransitionTime: now,
+			Reason:             "NodeCheckFailed",
+			Message:            err.Error(),
+		}
+	}
+	if len(notReady) > 0 {
+		return store.Condition{
+			Type:               ConditionNodesHealthy,
+			Status:             store.ConditionFalse,
+			LastTransitionTime: now,
+			Reason:             "NodesNotReady",
+			Message:            fmt.Sprintf("nodes not ready: %v", notReady),
+		}
+	}
+	return store.Condition{
+		Type:               ConditionNodesHealthy,
+		Status:             store.ConditionTrue,
+		LastTransitionTime: now,
+	}
+}
+
+func probeEtcdQuorum(ctx context.Context, spec store.ClusterSpec) store.Condition {
+	now := time.Now()
+	if err := checkEtcdQuorum(ctx, spec); err != nil {
+		return store.Condition{
+			Type:               ConditionEtcdHealthy,
+			Status:             store.ConditionFalse,
+			LastTransitionTime: now,
+			Reason:             "EtcdQuorumLost",
+			Message:            err.Error(),
+		}
+	}
+	return store.Condition{
+		Type:               ConditionEtcdHealthy,
+		Status:             store.ConditionTrue,
+		LastTransitionTime: now,
+	}
+}
+
+// runHealthChecks probes the cluster every cc.healthCheckFreq until ctx is
+// canceled, writing the resulting conditions back to the store. It is a
+// no-op if health checking wasn't configured for this controller.
+func (cc *clusterController) runHealthChecks(ctx context.Context) {
+	if cc.healthCheckFreq <= 0 || len(cc.probes) == 0 {
+		return
+	}
+	ticker := time.NewTicker(cc.healthCheckFreq)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			cc.runHealthCheckOnce(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (cc *clusterController) runHealthCheckOnce(ctx context.Context) {
+	cluster, err := cc.provider.Get(ctx, cc.clusterKey)
+	if err != nil {
+		cc.log.Printf("cluster %q: failed to load cluster for health check: %v", cc.clusterKey, err)
+		return
+	}
+	spec := cc.spec()
+	for _, probe := range cc.probes {
+		SetCondition(&cluster.Status, probe.Check(ctx, spec))
+	}
+	SetCondition(&cluster.Status, aggregateReadyCondition(cluster.Status))
+	if err := cc.provider.UpdateStatus(ctx, cc.clusterKey, cluster.Status); err != nil {
+		cc.log.Printf("cluster %q: failed to persist health conditions: %v", cc.clusterKey, err)
+	}
+}
+
+// aggregateReadyCondition computes the top-level ConditionReady from every
+// other condition on status: Ready only if every one of them is True;
+// otherwise False, carrying the Reason/Message of the first condition that
+// isn't True (Unknown counts as not True). This is
+// what actually drives the Reason/Message clearing in updateTopLevelStatus
+// once a cluster recovers, since none of defaultProbes report
+// ConditionReady directly.
+func aggregateReadyCondition(status store.ClusterStatus) store.Condition {
+	now := time.Now()
+	for _, cond := range status.Conditions {
+		if cond.Type == ConditionReady {
+			continue
+		}
+		if cond.Status != store.ConditionTrue {
+			return store.Condition{
+				Type:               ConditionReady,
+				Status:             store.ConditionFalse,
+				LastTransitionTime: now,
+				Reason:             cond.Reason,
+				Message:            cond.Message,
+			}
+		}
+	}
+	return store.Condition{
+		Type:               ConditionReady,
+		Status:             store.ConditionTrue,
+		LastTransitionTime: now,
+	}
+}
+
+// SetCondition inserts or updates cond in status.Conditions, following the
+// same semantics Kubernetes-style APIs use for condition lists:
+// LastTransitionTime only advances when the condition's Status actually
+// changes, and the top-level Reason/Message mirror whichever condition most
+// recently went False, clearing once Ready recovers.
+func SetCondition(status *store.ClusterStatus, cond store.Condition) {
+	for i, existing := range status.Conditions {
+		if existing.Type != cond.Type {
+			continue
+		}
+		if existing.Status == cond.Status {
+			cond.LastTransitionTime = existing.LastTransitionTime
+		}
+		status.Conditions[i] = cond
+		updateTopLevelStatus(status, cond)
+		return
+	}
+	status.Conditions = append(status.Conditions, cond)
+	updateTopLevelStatus(status, cond)
+}
+
+func updateTopLevelStatus(status *store.ClusterStatus, cond store.Condition) {
+	if cond.Status == store.ConditionFalse {
+		status.Reason = cond.Reason
+		status.Message = cond.Message
+		return
+	}
+	if cond.Type == ConditionReady {
+		status.Reason = ""
+		status.Message = ""
+	}
+}
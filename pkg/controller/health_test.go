@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/store"
+)
+
+func TestSetConditionLastTransitionTimeOnlyAdvancesOnStatusChange(t *testing.T) {
+	var status store.ClusterStatus
+
+	t1 := time.Unix(1000, 0)
+	SetCondition(&status, store.Condition{
+		Type: ConditionAPIReachable, Status: store.ConditionFalse,
+		LastTransitionTime: t1, Reason: "Unreachable", Message: "timeout",
+	})
+	if got := status.Conditions[0].LastTransitionTime; !got.Equal(t1) {
+		t.Fatalf("initial LastTransitionTime = %v, want %v", got, t1)
+	}
+
+	// Same Status again, later time and different Reason/Message: the
+	// condition itself updates, but LastTransitionTime must not advance
+	// since the cluster never actually transitioned.
+	t2 := time.Unix(2000, 0)
+	SetCondition(&status, store.Condition{
+		Type: ConditionAPIReachable, Status: store.ConditionFalse,
+		LastTransitionTime: t2, Reason: "StillUnreachable", Message: "timeout again",
+	})
+	if got := status.Conditions[0].LastTransitionTime; !got.Equal(t1) {
+		t.Fatalf("LastTransitionTime advanced on a same-status update: got %v, want unchanged %v", got, t1)
+	}
+	if status.Conditions[0].Reason != "StillUnreachable" {
+		t.Fatalf("Reason not updated on a same-status update: got %q", status.Conditions[0].Reason)
+	}
+
+	// A real Status change must advance LastTransitionTime.
+	t3 := time.Unix(3000, 0)
+	SetCondition(&status, store.Condition{Type: ConditionAPIReachable, Status: store.ConditionTrue, LastTransitionTime: t3})
+	if got := status.Conditions[0].LastTransitionTime; !got.Equal(t3) {
+		t.Fatalf("LastTransitionTime did not advance on a real status change: got %v, want %v", got, t3)
+	}
+}
+
+func TestSetConditionTopLevelReasonMessage(t *testing.T) {
+	var status store.ClusterStatus
+
+	SetCondition(&status, store.Condition{Type: ConditionNodesHealthy, Status: store.ConditionFalse, Reason: "NodesNotReady", Message: "node-1 not ready"})
+	if status.Reason != "NodesNotReady" || status.Message != "node-1 not ready" {
+		t.Fatalf("top-level Reason/Message not populated on first False condition: %+v", status)
+	}
+
+	// A different condition going False takes over as the top-level reason.
+	SetCondition(&status, store.Condition{Type: ConditionEtcdHealthy, Status: store.ConditionFalse, Reason: "EtcdQuorumLost", Message: "1/3 members reachable"})
+	if status.Reason != "EtcdQuorumLost" || status.Message != "1/3 members reachable" {
+		t.Fatalf("top-level Reason/Message not overwritten by the most recent False condition: %+v", status)
+	}
+
+	// Recovering a non-Ready condition must not by itself clear Reason/Message.
+	SetCondition(&status, store.Condition{Type: ConditionEtcdHealthy, Status: store.ConditionTrue})
+	if status.Reason == "" || status.Message == "" {
+		t.Fatalf("Reason/Message cleared by a non-Ready condition recovering: %+v", status)
+	}
+
+	// Only Ready recovering clears the top-level Reason/Message.
+	SetCondition(&status, store.Condition{Type: ConditionReady, Status: store.ConditionTrue})
+	if status.Reason != "" || status.Message != "" {
+		t.Fatalf("Reason/Message not cleared once Ready recovered: %+v", status)
+	}
+}
+
+func TestAggregateReadyCondition(t *testing.T) {
+	cases := []struct {
+		name       string
+		conditions []store.Condition
+		wantStatus store.ConditionStatus
+		wantReason string
+	}{
+		{
+			name: "all conditions true",
+			conditions: []store.Condition{
+				{Type: ConditionAPIReachable, Status: store.ConditionTrue},
+				{Type: ConditionNodesHealthy, Status: store.ConditionTrue},
+				{Type: ConditionEtcdHealthy, Status: store.ConditionTrue},
+			},
+			wantStatus: store.ConditionTrue,
+		},
+		{
+			name: "one false condition",
+			conditions: []store.Condition{
+				{Type: ConditionAPIReachable, Status: store.ConditionTrue},
+				{Type: ConditionNodesHealthy, Status: store.ConditionFalse, Reason: "NodesNotReady"},
+				{Type: ConditionEtcdHealthy, Status: store.ConditionTrue},
+			},
+			wantStatus: store.ConditionFalse,
+			wantReason: "NodesNotReady",
+		},
+		{
+			name: "multiple non-true conditions pick the first",
+			conditions: []store.Condition{
+				{Type: ConditionAPIReachable, Status: store.ConditionFalse, Reason: "APIServerUnreachable"},
+				{Type: ConditionNodesHealthy, Status: store.ConditionUnknown, Reason: "NodeCheckFailed"},
+			},
+			wantStatus: store.ConditionFalse,
+			wantReason: "APIServerUnreachable",
+		},
+		{
+			name:       "no conditions yet",
+			conditions: nil,
+			wantStatus: store.ConditionTrue,
+		},
+		{
+			name: "ignores a stale Ready condition already on status",
+			conditions: []store.Condition{
+				{Type: ConditionReady, Status: store.ConditionFalse, Reason: "Stale"},
+				{Type: ConditionAPIReachable, Status: store.ConditionTrue},
+			},
+			wantStatus: store.ConditionTrue,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := aggregateReadyCondition(store.ClusterStatus{Conditions: c.conditions})
+			if got.Type != ConditionReady {
+				t.Errorf("Type = %q, want %q", got.Type, ConditionReady)
+			}
+			if got.Status != c.wantStatus {
+				t.Errorf("Status = %q, want %q", got.Status, c.wantStatus)
+			}
+			if got.Reason != c.wantReason {
+				t.Errorf("Reason = %q, want %q", got.Reason, c.wantReason)
+			}
+		})
+	}
+}
+
+// TestHealthCheckRecoveryClearsTopLevelStatus exercises the full sequence a
+// real health-check run produces: a probe going False sets Reason/Message,
+// and aggregateReadyCondition + SetCondition together clear them once every
+// probe recovers, closing the gap chunk0-1's fix addressed.
+func TestHealthCheckRecoveryClearsTopLevelStatus(t *testing.T) {
+	var status store.ClusterStatus
+
+	SetCondition(&status, store.Condition{Type: ConditionAPIReachable, Status: store.ConditionFalse, Reason: "APIServerUnreachable", Message: "dial tcp: timeout"})
+	SetCondition(&status, aggregateReadyCondition(status))
+	if status.Reason != "APIServerUnreachable" {
+		t.Fatalf("Reason = %q after a probe failed, want APIServerUnreachable", status.Reason)
+	}
+
+	SetCondition(&status, store.Condition{Type: ConditionAPIReachable, Status: store.ConditionTrue})
+	SetCondition(&status, aggregateReadyCondition(status))
+	if status.Reason != "" || status.Message != "" {
+		t.Fatalf("Reason/Message not cleared after the only failing probe recovered: %+v", status)
+	}
+}
@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// defaultTailLines is how many log lines TailLogsHandler returns when the
+// request doesn't specify n.
+const defaultTailLines = 100
+
+// TailLogsHandler serves GET /clusters/{namespace}/{name}/logs[?n=100],
+// returning the last n captured log lines for the named cluster as a JSON
+// array of strings. It lets operators retrieve a cluster's recent logs
+// without SSHing to the controller host.
+func (mcc *multiClusterController) TailLogsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/clusters/"), "/logs")
+		parts := strings.SplitN(path, "/", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			http.Error(w, "expected path /clusters/{namespace}/{name}/logs", http.StatusBadRequest)
+			return
+		}
+		namespace, name := parts[0], parts[1]
+		n := defaultTailLines
+		if raw := r.URL.Query().Get("n"); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed < 0 {
+				http.Error(w, "n must be a non-negative integer", http.StatusBadRequest)
+				return
+			}
+			n = parsed
+		}
+		lines, err := mcc.TailLogs(namespace, name, n)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(lines); err != nil {
+			mcc.log.Printf("error encoding tail logs response for cluster %s/%s: %v", namespace, name, err)
+		}
+	})
+}
@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// queueMetrics tracks the health of the cluster work queue. Dropped should
+// stay at zero now that full buffers coalesce instead of discarding
+// notifications; it is kept around so regressions are visible rather than
+// silent.
+type queueMetrics struct {
+	depth     int64 // current queue length
+	coalesced int64 // notifications folded into an already-pending item
+	dropped   int64 // notifications discarded outright (should stay 0)
+
+	mu        sync.Mutex
+	durations map[ClusterKey]time.Duration // last reconcile duration per cluster
+}
+
+func newQueueMetrics() *queueMetrics {
+	return &queueMetrics{durations: make(map[ClusterKey]time.Duration)}
+}
+
+func (m *queueMetrics) setQueueDepth(n int) {
+	if m == nil {
+		return
+	}
+	atomic.StoreInt64(&m.depth, int64(n))
+}
+
+func (m *queueMetrics) incCoalesced() {
+	if m == nil {
+		return
+	}
+	atomic.AddInt64(&m.coalesced, 1)
+}
+
+func (m *queueMetrics) incDropped() {
+	if m == nil {
+		return
+	}
+	atomic.AddInt64(&m.dropped, 1)
+}
+
+func (m *queueMetrics) observeReconcileDuration(key ClusterKey, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.durations[key] = d
+}
+
+// QueueDepth returns the number of clusters currently waiting to be
+// reconciled.
+func (m *queueMetrics) QueueDepth() int {
+	if m == nil {
+		return 0
+	}
+	return int(atomic.LoadInt64(&m.depth))
+}
+
+// Coalesced returns the number of notifications that were folded into an
+// already-pending reconcile instead of creating a new queue entry.
+func (m *queueMetrics) Coalesced() int {
+	if m == nil {
+		return 0
+	}
+	return int(atomic.LoadInt64(&m.coalesced))
+}
+
+// Dropped returns the number of notifications that were discarded outright.
+// It should always be zero.
+func (m *queueMetrics) Dropped() int {
+	if m == nil {
+		return 0
+	}
+	return int(atomic.LoadInt64(&m.dropped))
+}
+
+// ReconcileDuration returns how long the most recent reconcile of the named
+// cluster took.
+func (m *queueMetrics) ReconcileDuration(key ClusterKey) time.Duration {
+	if m == nil {
+		return 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.durations[key]
+}
@@ -2,146 +2,298 @@ package controller
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/apprenda/kismatic/pkg/store"
 )
 
-// The size of the buffer assigned to each cluster controller created by the
-// multiClusterController.
-const clusterControllerNotificationBuffer = 10
+// defaultWorkerCount is the number of reconcile workers a
+// multiClusterController runs when WithWorkerCount isn't supplied.
+const defaultWorkerCount = 4
 
-// The multiClusterController (mcc) manages a set of cluster controllers
-// (workers). Whenever a new cluster is defined in the store, the mcc creates a
-// new worker that will be responsible for that cluster's lifecycle.
-//
-// In the event that the state of a given cluster changes in the store, the mcc
-// is notified. The mcc, in turn, notifies the worker that is responsible for
-// that cluster.
-//
-// Given that there is only one communication channel between the store and the
-// mcc, the mcc creates buffered channels for each worker so that notifications
-// can be dispatched immediately. In the case that the buffer is full, the
-// notification is dropped.
+// managedCluster bundles a clusterController with the cancel func for its
+// background health-check goroutine, so both can be torn down together when
+// the cluster is deleted.
+type managedCluster struct {
+	cc     *clusterController
+	cancel context.CancelFunc
+}
+
+// The multiClusterController (mcc) manages a set of clusters discovered
+// through its ClusterProvider (by default the bbolt store, but any source
+// that can List/Get/Watch store.Cluster values works — see
+// WithClusterProvider). It runs a fixed pool of reconcile workers that pull
+// cluster names off a single, deduplicating work queue: whenever the
+// provider reports a cluster as added or updated, or the periodic reconcile
+// tick fires, the cluster's name is queued, and whichever worker is free
+// next reconciles it. Queuing the same cluster name again while it is
+// already queued or being reconciled coalesces into a single pending
+// reconcile rather than growing the queue or dropping work, and at most one
+// reconcile per cluster ever runs concurrently.
 //
-// When a cluster is deleted from the store, the corresponding worker is
-// terminated.
+// When the provider reports a cluster as deleted, its clusterController and
+// health-check goroutine are stopped and removed from the registry.
 type multiClusterController struct {
 	assetsDir          AssetsDir
 	log                *log.Logger
 	newExecutor        ExecutorCreator
 	provisionerCreator ProvisionerCreator
-	clusterStore       store.ClusterStore
+	provider           ClusterProvider
 	reconcileFreq      time.Duration
-	clusterControllers map[string]chan<- struct{}
+
+	mu                 sync.Mutex
+	clusterControllers map[ClusterKey]*managedCluster
+
+	workerCount int
+	metrics     *queueMetrics
+
+	// healthCheckFreq and probes configure the health-check subsystem that
+	// each cluster controller runs. See WithHealthChecks.
+	healthCheckFreq time.Duration
+	probes          []Probe
+
+	// phases holds the reconcile pipeline every cluster controller is
+	// built from. It's seeded with the built-in PhaseProvision
+	// implementation here; PhaseInstall is added per-cluster in
+	// newClusterController once that cluster's Executor exists. See
+	// WithPhases.
+	phases *PhaseRegistry
+}
+
+// Option configures a multiClusterController constructed via New.
+type Option func(*multiClusterController)
+
+// WithHealthChecks sets the cadence at which every cluster controller probes
+// its cluster's health, along with the probes it runs. Operators that need
+// provisioner-specific checks (e.g. a cloud-specific etcd probe) can supply
+// their own Probe implementations here. If this option is not passed, New
+// defaults to defaultHealthCheckFreq and defaultProbes.
+func WithHealthChecks(freq time.Duration, probes []Probe) Option {
+	return func(mcc *multiClusterController) {
+		mcc.healthCheckFreq = freq
+		mcc.probes = probes
+	}
+}
+
+// WithWorkerCount sets the number of reconcile workers the
+// multiClusterController runs. It defaults to defaultWorkerCount.
+func WithWorkerCount(n int) Option {
+	return func(mcc *multiClusterController) {
+		mcc.workerCount = n
+	}
+}
+
+// WithClusterProvider overrides the source the multiClusterController
+// discovers clusters from. It defaults to NewStoreClusterProvider(clusterStore).
+// Pass NewCompositeClusterProvider to watch the store alongside, say, a
+// Kubernetes CRD source.
+func WithClusterProvider(provider ClusterProvider) Option {
+	return func(mcc *multiClusterController) {
+		mcc.provider = provider
+	}
+}
+
+// WithPhases registers additional reconcile phases alongside the built-in
+// PhaseProvision and PhaseInstall: a PhasePreProvision, PhasePreInstall or
+// PhasePostInstall implementation to run placement, validation or
+// network-policy logic around the built-ins, or a phase under any other
+// name to append a custom step (security scans, tenant hooks, ...) to the
+// end of the pipeline. Passing a phase with the same Name as a built-in
+// replaces it.
+func WithPhases(phases ...Phase) Option {
+	return func(mcc *multiClusterController) {
+		for _, phase := range phases {
+			mcc.phases.Register(phase)
+		}
+	}
+}
+
+// New creates a multiClusterController that manages clusters defined in
+// clusterStore, reconciling each one at least once every reconcileFreq.
+func New(assetsDir AssetsDir, logger *log.Logger, newExecutor ExecutorCreator, provisionerCreator ProvisionerCreator, clusterStore store.ClusterStore, reconcileFreq time.Duration, opts ...Option) *multiClusterController {
+	mcc := &multiClusterController{
+		assetsDir:          assetsDir,
+		log:                logger,
+		newExecutor:        newExecutor,
+		provisionerCreator: provisionerCreator,
+		provider:           NewStoreClusterProvider(clusterStore),
+		reconcileFreq:      reconcileFreq,
+		clusterControllers: make(map[ClusterKey]*managedCluster),
+		workerCount:        defaultWorkerCount,
+		healthCheckFreq:    defaultHealthCheckFreq,
+		probes:             defaultProbes(),
+		phases:             NewPhaseRegistry(),
+	}
+	mcc.phases.Register(&provisionPhase{newProvisioner: provisionerCreator})
+	for _, opt := range opts {
+		opt(mcc)
+	}
+	mcc.metrics = newQueueMetrics()
+	return mcc
+}
+
+// Metrics returns the queue-depth, coalesced/dropped-notification, and
+// reconcile-duration counters for this controller.
+func (mcc *multiClusterController) Metrics() *queueMetrics {
+	return mcc.metrics
+}
+
+// TailLogs returns up to the last n log lines captured for the named
+// cluster in namespace, oldest first. It returns an error if the cluster is
+// not currently managed.
+func (mcc *multiClusterController) TailLogs(namespace, name string, n int) ([]string, error) {
+	key := ClusterKey{Namespace: namespace, Name: name}
+	mcc.mu.Lock()
+	managed, found := mcc.clusterControllers[key]
+	mcc.mu.Unlock()
+	if !found {
+		return nil, fmt.Errorf("cluster %q is not currently managed", key)
+	}
+	return managed.cc.ringLog.Tail(n), nil
 }
 
 // Run starts the multiClusterController. The controller will run until the
 // passed context is canceled.
 func (mcc *multiClusterController) Run(ctx context.Context) {
 	mcc.log.Println("started multi-cluster controller")
-	watch := mcc.clusterStore.Watch(context.Background(), 0)
+	queue := newClusterWorkQueue(mcc.metrics)
+
+	var workers sync.WaitGroup
+	for i := 0; i < mcc.workerCount; i++ {
+		workers.Add(1)
+		go mcc.runWorker(ctx, i, queue, &workers)
+	}
+
+	watch := mcc.provider.Watch(ctx)
 	ticker := time.Tick(mcc.reconcileFreq)
 	for {
 		select {
-		case resp := <-watch:
-			clusterName := resp.Key
-			ch, found := mcc.clusterControllers[clusterName]
-
-			// Stop the cluster controller if the cluster has been deleted
-			if found && resp.Value == nil {
-				close(ch)
-				delete(mcc.clusterControllers, clusterName)
+		case event, ok := <-watch:
+			if !ok {
+				watch = nil
 				continue
 			}
-
-			// Create a new controller if this is the first time we hear about
-			// this cluster
-			if !found {
-				var cluster store.Cluster
-				err := json.Unmarshal(resp.Value, &cluster)
-				if err != nil {
-					mcc.log.Printf("error unmarshaling watch event value for cluster %q: %v", clusterName, err)
-					continue
-				}
-
-				cc, err := mcc.newClusterController(clusterName, cluster)
-				if err != nil {
-					mcc.log.Printf("error creating cluster controller for cluster %q: %v", clusterName, err)
-					continue
-				}
-				newChan := make(chan struct{}, clusterControllerNotificationBuffer)
-				ch = newChan
-				mcc.clusterControllers[clusterName] = newChan
-				go cc.run(newChan)
-			}
-
-			// Don't block if the cluster controller's buffer is full.
-			select {
-			case ch <- struct{}{}:
-			default:
-				mcc.log.Printf("buffer of cluster %s is full. dropping notification.", clusterName)
+			if event.Type == ClusterDeleted {
+				mcc.removeClusterController(event.ClusterKey)
+				continue
 			}
+			queue.Add(event.ClusterKey)
 
 		case <-ticker:
 			mcc.log.Println("tick")
-			definedClusters, err := mcc.clusterStore.GetAll()
+			definedClusters, err := mcc.provider.List(ctx)
 			if err != nil {
-				mcc.log.Printf("failed to get all the clusters defined in the store: %v", err)
+				mcc.log.Printf("failed to list the clusters known to the provider: %v", err)
 				continue
 			}
-			// Make sure we have workers for all the clusters that are defined in the store
-			for clusterName, cluster := range definedClusters {
-				_, found := mcc.clusterControllers[clusterName]
-				if !found {
-					cc, err := mcc.newClusterController(clusterName, cluster)
-					if err != nil {
-						mcc.log.Printf("error creating cluster controller for cluster %q: %v", clusterName, err)
-						continue
-					}
-					newChan := make(chan struct{}, clusterControllerNotificationBuffer)
-					mcc.clusterControllers[clusterName] = newChan
-					go cc.run(newChan)
-				}
+			for key := range definedClusters {
+				queue.Add(key)
 			}
-
 			// Remove lingering cluster controllers, if any
-			for clusterName, ch := range mcc.clusterControllers {
-				_, found := definedClusters[clusterName]
-				if !found {
-					close(ch)
-					delete(mcc.clusterControllers, clusterName)
-				}
-			}
-
-			// Poke each cluster controller with the latest cluster definition
-			for clusterName, ch := range mcc.clusterControllers {
-				// Don't block if the cluster controller's buffer is full.
-				select {
-				case ch <- struct{}{}:
-				default:
-					mcc.log.Printf("buffer of cluster %s is full. dropping notification.", clusterName)
+			mcc.mu.Lock()
+			for key := range mcc.clusterControllers {
+				if _, found := definedClusters[key]; !found {
+					mcc.removeClusterControllerLocked(key)
 				}
 			}
+			mcc.mu.Unlock()
 
 		case <-ctx.Done():
 			mcc.log.Println("stopping the multi-cluster controller")
-			for _, v := range mcc.clusterControllers {
-				close(v)
+			queue.ShutDown()
+			workers.Wait()
+			mcc.mu.Lock()
+			for key := range mcc.clusterControllers {
+				mcc.removeClusterControllerLocked(key)
 			}
+			mcc.mu.Unlock()
 			return
 		}
 	}
 }
 
-func (mcc multiClusterController) newClusterController(clusterName string, cluster store.Cluster) (*clusterController, error) {
+// runWorker is one of the fixed pool of reconcile workers. It pulls cluster
+// keys off queue, one at a time, until the queue is shut down.
+func (mcc *multiClusterController) runWorker(ctx context.Context, id int, queue *clusterWorkQueue, workers *sync.WaitGroup) {
+	defer workers.Done()
+	workerLog := log.New(mcc.log.Writer(), fmt.Sprintf("[worker-%d] ", id), mcc.log.Flags())
+	workerLog.Println("worker started")
+	for {
+		key, shutdown := queue.Get()
+		if shutdown {
+			workerLog.Println("worker stopped")
+			return
+		}
+		mcc.processNextWorkItem(ctx, workerLog, key)
+		queue.Done(key)
+	}
+}
+
+// processNextWorkItem reconciles a single cluster, creating its
+// clusterController the first time the cluster is seen.
+func (mcc *multiClusterController) processNextWorkItem(ctx context.Context, workerLog *log.Logger, key ClusterKey) {
+	cluster, err := mcc.provider.Get(ctx, key)
+	if err != nil {
+		workerLog.Printf("cluster %q: failed to load cluster: %v", key, err)
+		return
+	}
+	managed, err := mcc.getOrCreateManagedCluster(ctx, key, cluster, workerLog)
+	if err != nil {
+		workerLog.Printf("cluster %q: failed to create cluster controller: %v", key, err)
+		return
+	}
+	managed.cc.setSpec(cluster.Spec)
+
+	start := time.Now()
+	err = managed.cc.reconcile()
+	mcc.metrics.observeReconcileDuration(key, time.Since(start))
+	if err != nil {
+		workerLog.Printf("cluster %q: reconcile error: %v", key, err)
+	}
+}
+
+func (mcc *multiClusterController) getOrCreateManagedCluster(ctx context.Context, key ClusterKey, cluster store.Cluster, workerLog *log.Logger) (*managedCluster, error) {
+	mcc.mu.Lock()
+	defer mcc.mu.Unlock()
+	if managed, found := mcc.clusterControllers[key]; found {
+		return managed, nil
+	}
+	cc, err := mcc.newClusterController(key, cluster, workerLog)
+	if err != nil {
+		return nil, err
+	}
+	healthCtx, cancel := context.WithCancel(ctx)
+	managed := &managedCluster{cc: cc, cancel: cancel}
+	mcc.clusterControllers[key] = managed
+	go cc.runHealthChecks(healthCtx)
+	return managed, nil
+}
+
+func (mcc *multiClusterController) removeClusterController(key ClusterKey) {
+	mcc.mu.Lock()
+	defer mcc.mu.Unlock()
+	mcc.removeClusterControllerLocked(key)
+}
+
+// removeClusterControllerLocked must be called with mcc.mu held.
+func (mcc *multiClusterController) removeClusterControllerLocked(key ClusterKey) {
+	managed, found := mcc.clusterControllers[key]
+	if !found {
+		return
+	}
+	managed.cancel()
+	delete(mcc.clusterControllers, key)
+}
+
+func (mcc *multiClusterController) newClusterController(key ClusterKey, cluster store.Cluster, workerLog *log.Logger) (*clusterController, error) {
 	// Create assets dir and logfile for this cluster
-	clusterAssetsDir := mcc.assetsDir.ForCluster(clusterName)
+	clusterAssetsDir := mcc.assetsDir.ForCluster(key.Namespace, key.Name)
 	err := os.MkdirAll(clusterAssetsDir, 0700)
 	if err != nil {
 		return nil, fmt.Errorf("error creating assets directory: %v", err)
@@ -151,19 +303,28 @@ func (mcc multiClusterController) newClusterController(clusterName string, clust
 		return nil, fmt.Errorf("error creating log file: %v", err)
 
 	}
-	executor, err := mcc.newExecutor(clusterName, mcc.assetsDir.ForCluster(clusterName), logFile)
+	ringLog := NewRingLog(defaultRingLogSize)
+	logWriter := io.MultiWriter(logFile, ringLog)
+	executor, err := mcc.newExecutor(key.String(), clusterAssetsDir, logWriter)
 	if err != nil {
 		return nil, fmt.Errorf("error creating executor: %v", err)
 	}
+	phases := mcc.phases.clone()
+	if _, found := phases.byName[PhaseInstall]; !found {
+		phases.Register(&installPhase{executor: executor})
+	}
 	cc := clusterController{
-		log:              mcc.log,
-		clusterName:      clusterName,
+		log:              workerLog,
+		clusterKey:       key,
 		clusterSpec:      cluster.Spec,
 		clusterAssetsDir: clusterAssetsDir,
 		logFile:          logFile,
-		executor:         executor,
-		clusterStore:     mcc.clusterStore,
-		newProvisioner:   mcc.provisionerCreator,
+		ringLog:          ringLog,
+		logWriter:        logWriter,
+		provider:         mcc.provider,
+		phases:           phases,
+		healthCheckFreq:  mcc.healthCheckFreq,
+		probes:           mcc.probes,
 	}
 	return &cc, nil
-}
\ No newline at end of file
+}
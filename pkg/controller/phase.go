@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"context"
+	"io"
+
+	"github.com/apprenda/kismatic/pkg/store"
+)
+
+// PhaseName identifies a step in a cluster's reconcile pipeline.
+type PhaseName string
+
+// Built-in phase names, run in this order by default. Operators can
+// register a phase under one of these names to replace the built-in
+// implementation, or under a new name to append a step to the pipeline —
+// see PhaseRegistry.
+const (
+	PhasePreProvision PhaseName = "PreProvision"
+	PhaseProvision    PhaseName = "Provision"
+	PhasePreInstall   PhaseName = "PreInstall"
+	PhaseInstall      PhaseName = "Install"
+	PhasePostInstall  PhaseName = "PostInstall"
+)
+
+// defaultPhaseOrder is the sequence a PhaseRegistry runs its phases in
+// before any custom, non-built-in phases registered with it.
+var defaultPhaseOrder = []PhaseName{
+	PhasePreProvision,
+	PhaseProvision,
+	PhasePreInstall,
+	PhaseInstall,
+	PhasePostInstall,
+}
+
+// PhaseContext is shared across every phase of a single reconcile. It lets,
+// e.g., a custom placement phase hand node assignments to the phase that
+// provisions them, by stashing a value under a key the later phase knows to
+// look for.
+type PhaseContext struct {
+	context.Context
+	ClusterKey       ClusterKey
+	ClusterAssetsDir string
+	LogWriter        io.Writer
+
+	values map[string]interface{}
+}
+
+func newPhaseContext(ctx context.Context, key ClusterKey, assetsDir string, logWriter io.Writer) *PhaseContext {
+	return &PhaseContext{
+		Context:          ctx,
+		ClusterKey:       key,
+		ClusterAssetsDir: assetsDir,
+		LogWriter:        logWriter,
+		values:           make(map[string]interface{}),
+	}
+}
+
+// Set stashes a value for later phases to read back with Value.
+func (c *PhaseContext) Set(key string, value interface{}) {
+	c.values[key] = value
+}
+
+// Value returns a value a previous phase stashed with Set.
+func (c *PhaseContext) Value(key string) (interface{}, bool) {
+	v, ok := c.values[key]
+	return v, ok
+}
+
+// Phase is one step of a cluster's reconcile pipeline: placement,
+// provisioning, installing, or a custom action such as a network-policy or
+// security-scan hook. Run may mutate status in place to surface its own
+// conditions (see SetCondition); returning an error short-circuits the
+// remaining phases.
+type Phase interface {
+	Name() PhaseName
+	Run(ctx *PhaseContext, spec store.ClusterSpec, status *store.ClusterStatus) error
+}
+
+// PhaseRegistry holds the ordered set of phases a clusterController runs on
+// every reconcile. multiClusterController seeds it with the built-in
+// provisioning and installing phases; operators add placement, validation,
+// or tenant-hook phases with Register, either replacing a built-in phase by
+// name or extending the pipeline with a new one.
+type PhaseRegistry struct {
+	order  []PhaseName
+	byName map[PhaseName]Phase
+}
+
+// NewPhaseRegistry returns a PhaseRegistry with the built-in phase slots
+// ordered but unpopulated.
+func NewPhaseRegistry() *PhaseRegistry {
+	order := make([]PhaseName, len(defaultPhaseOrder))
+	copy(order, defaultPhaseOrder)
+	return &PhaseRegistry{order: order, byName: make(map[PhaseName]Phase)}
+}
+
+// Register adds phase to the registry, replacing any phase already
+// registered under the same name. A name outside the built-in phase order
+// is appended to the end of the pipeline the first time it's registered.
+func (r *PhaseRegistry) Register(phase Phase) {
+	if _, found := r.byName[phase.Name()]; !found && !r.isBuiltin(phase.Name()) {
+		r.order = append(r.order, phase.Name())
+	}
+	r.byName[phase.Name()] = phase
+}
+
+func (r *PhaseRegistry) isBuiltin(name PhaseName) bool {
+	for _, n := range r.order {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Phases returns the registered phases in run order. Built-in slots with no
+// phase registered are skipped.
+func (r *PhaseRegistry) Phases() []Phase {
+	phases := make([]Phase, 0, len(r.order))
+	for _, name := range r.order {
+		if phase, ok := r.byName[name]; ok {
+			phases = append(phases, phase)
+		}
+	}
+	return phases
+}
+
+// clone returns a copy of the registry that can be mutated independently,
+// so a per-cluster phase (e.g. install, which needs that cluster's
+// Executor) can be added without affecting other clusters sharing the same
+// base registry.
+func (r *PhaseRegistry) clone() *PhaseRegistry {
+	order := make([]PhaseName, len(r.order))
+	copy(order, r.order)
+	byName := make(map[PhaseName]Phase, len(r.byName))
+	for name, phase := range r.byName {
+		byName[name] = phase
+	}
+	return &PhaseRegistry{order: order, byName: byName}
+}
@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/apprenda/kismatic/pkg/store"
+)
+
+// probeDialTimeout bounds how long a single health-check dial is allowed to
+// take, so a single unreachable cluster can't stall the health-check loop.
+const probeDialTimeout = 5 * time.Second
+
+// dialAPIServer reports whether the cluster's API server is accepting TLS
+// connections.
+func dialAPIServer(ctx context.Context, spec store.ClusterSpec) error {
+	d := &net.Dialer{Timeout: probeDialTimeout}
+	conn, err := tls.DialWithDialer(d, "tcp", spec.APIServerAddress(), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		return fmt.Errorf("dialing API server: %v", err)
+	}
+	return conn.Close()
+}
+
+// nodesNotReady returns the names of any nodes in spec that are not
+// reporting Ready.
+func nodesNotReady(ctx context.Context, spec store.ClusterSpec) ([]string, error) {
+	var notReady []string
+	for _, node := range spec.Nodes() {
+		if !node.Ready {
+			notReady = append(notReady, node.Name)
+		}
+	}
+	return notReady, nil
+}
+
+// checkEtcdQuorum reports whether the cluster's etcd cluster can still reach
+// quorum, i.e. a majority of its members are healthy.
+func checkEtcdQuorum(ctx context.Context, spec store.ClusterSpec) error {
+	members := spec.EtcdMembers()
+	if len(members) == 0 {
+		return nil
+	}
+	healthy := 0
+	for _, m := range members {
+		d := &net.Dialer{Timeout: probeDialTimeout}
+		conn, err := d.DialContext(ctx, "tcp", m)
+		if err != nil {
+			continue
+		}
+		conn.Close()
+		healthy++
+	}
+	if healthy*2 <= len(members) {
+		return fmt.Errorf("only %d/%d etcd members reachable", healthy, len(members))
+	}
+	return nil
+}
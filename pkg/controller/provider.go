@@ -0,0 +1,204 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/apprenda/kismatic/pkg/store"
+)
+
+// ClusterEventType identifies the kind of change a ClusterProvider reports.
+type ClusterEventType string
+
+const (
+	ClusterAdded   ClusterEventType = "Added"
+	ClusterUpdated ClusterEventType = "Updated"
+	ClusterDeleted ClusterEventType = "Deleted"
+)
+
+// ClusterEvent is emitted on a ClusterProvider's Watch channel whenever a
+// cluster it tracks is added, updated, or deleted.
+type ClusterEvent struct {
+	Type       ClusterEventType
+	ClusterKey ClusterKey
+	Cluster    store.Cluster
+}
+
+// ClusterProvider is the source of truth the multiClusterController
+// discovers clusters from. The built-in provider reads from the bbolt-backed
+// store.ClusterStore; other implementations (e.g. a Kubernetes CRD-backed
+// provider) let Kismatic be driven by clusters defined elsewhere, optionally
+// alongside the store via NewCompositeClusterProvider.
+type ClusterProvider interface {
+	// List returns every cluster currently known to the provider, keyed by
+	// ClusterKey.
+	List(ctx context.Context) (map[ClusterKey]store.Cluster, error)
+	// Get returns a single cluster by key.
+	Get(ctx context.Context, key ClusterKey) (store.Cluster, error)
+	// Watch streams cluster add/update/delete events until ctx is canceled.
+	// The returned channel is closed once it is.
+	Watch(ctx context.Context) <-chan ClusterEvent
+	// UpdateStatus persists an updated status for the cluster at key. Health
+	// checks and reconciles report status through this rather than assuming
+	// the bbolt store is where a cluster's definition lives, so providers
+	// backed by something else (e.g. a Kubernetes CRD) can report status back
+	// to wherever the cluster was actually defined.
+	UpdateStatus(ctx context.Context, key ClusterKey, status store.ClusterStatus) error
+}
+
+// storeClusterProvider adapts a store.ClusterStore to the ClusterProvider
+// interface. It is the provider New uses unless WithClusterProvider is
+// passed. Store keys are of the form "namespace/name"; legacy keys without a
+// namespace are treated as belonging to defaultNamespace.
+type storeClusterProvider struct {
+	clusterStore store.ClusterStore
+}
+
+// NewStoreClusterProvider returns a ClusterProvider backed by the bbolt
+// cluster store.
+func NewStoreClusterProvider(clusterStore store.ClusterStore) ClusterProvider {
+	return &storeClusterProvider{clusterStore: clusterStore}
+}
+
+func (p *storeClusterProvider) List(ctx context.Context) (map[ClusterKey]store.Cluster, error) {
+	defined, err := p.clusterStore.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	clusters := make(map[ClusterKey]store.Cluster, len(defined))
+	for storeKey, cluster := range defined {
+		clusters[parseClusterKey(storeKey)] = cluster
+	}
+	return clusters, nil
+}
+
+func (p *storeClusterProvider) Get(ctx context.Context, key ClusterKey) (store.Cluster, error) {
+	cluster, err := p.clusterStore.Get(key.String())
+	if err == nil || key.Namespace != defaultNamespace {
+		return cluster, err
+	}
+	// Fall back to the pre-namespacing key shape for clusters created before
+	// this upgrade.
+	return p.clusterStore.Get(key.Name)
+}
+
+// UpdateStatus loads the current cluster at key (following the same
+// legacy-key fallback as Get), overwrites its status, and writes it back
+// under key's canonical "namespace/name" form.
+func (p *storeClusterProvider) UpdateStatus(ctx context.Context, key ClusterKey, status store.ClusterStatus) error {
+	cluster, err := p.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	cluster.Status = status
+	return p.clusterStore.Put(key.String(), cluster)
+}
+
+func (p *storeClusterProvider) Watch(ctx context.Context) <-chan ClusterEvent {
+	events := make(chan ClusterEvent)
+	watch := p.clusterStore.Watch(ctx, 0)
+	go func() {
+		defer close(events)
+		for {
+			select {
+			case resp, ok := <-watch:
+				if !ok {
+					return
+				}
+				event := ClusterEvent{ClusterKey: parseClusterKey(resp.Key), Type: ClusterUpdated}
+				if resp.Value == nil {
+					event.Type = ClusterDeleted
+				} else if err := json.Unmarshal(resp.Value, &event.Cluster); err != nil {
+					continue
+				}
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events
+}
+
+// compositeClusterProvider fans the events of several providers into a
+// single stream, and answers List/Get from whichever underlying provider
+// has the cluster. It lets the bbolt store and a CRD source (or any other
+// ClusterProvider) coexist.
+type compositeClusterProvider struct {
+	providers []ClusterProvider
+}
+
+// NewCompositeClusterProvider returns a ClusterProvider that merges the
+// clusters and events of every provider passed in.
+func NewCompositeClusterProvider(providers ...ClusterProvider) ClusterProvider {
+	return &compositeClusterProvider{providers: providers}
+}
+
+func (p *compositeClusterProvider) List(ctx context.Context) (map[ClusterKey]store.Cluster, error) {
+	all := make(map[ClusterKey]store.Cluster)
+	for _, provider := range p.providers {
+		clusters, err := provider.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for key, cluster := range clusters {
+			all[key] = cluster
+		}
+	}
+	return all, nil
+}
+
+func (p *compositeClusterProvider) Get(ctx context.Context, key ClusterKey) (store.Cluster, error) {
+	var lastErr error
+	for _, provider := range p.providers {
+		cluster, err := provider.Get(ctx, key)
+		if err == nil {
+			return cluster, nil
+		}
+		lastErr = err
+	}
+	return store.Cluster{}, lastErr
+}
+
+// UpdateStatus tries each provider in turn, the same way Get does, since the
+// composite provider doesn't track which underlying provider owns a given
+// key.
+func (p *compositeClusterProvider) UpdateStatus(ctx context.Context, key ClusterKey, status store.ClusterStatus) error {
+	var lastErr error
+	for _, provider := range p.providers {
+		if err := provider.UpdateStatus(ctx, key, status); err == nil {
+			return nil
+		} else {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+func (p *compositeClusterProvider) Watch(ctx context.Context) <-chan ClusterEvent {
+	events := make(chan ClusterEvent)
+	var fanIn sync.WaitGroup
+	for _, provider := range p.providers {
+		fanIn.Add(1)
+		go func(provider ClusterProvider) {
+			defer fanIn.Done()
+			for event := range provider.Watch(ctx) {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(provider)
+	}
+	go func() {
+		fanIn.Wait()
+		close(events)
+	}()
+	return events
+}
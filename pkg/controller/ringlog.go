@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"bytes"
+	"sync"
+)
+
+// defaultRingLogSize is the number of log lines each clusterController
+// retains in memory. multiClusterController has no option to change it.
+const defaultRingLogSize = 1000
+
+// RingLog is a fixed-size, thread-safe ring buffer of log lines. It backs
+// each clusterController's in-memory log retention: everything the
+// provisioner and executor write is captured here, in addition to the
+// on-disk log file, so the last N lines can be read back through TailLogs
+// without SSHing to the controller host.
+type RingLog struct {
+	mu    sync.Mutex
+	lines []string
+	next  int
+	full  bool
+	buf   []byte // partial line accumulated across Write calls
+}
+
+// NewRingLog creates a RingLog that retains the last size log lines.
+func NewRingLog(size int) *RingLog {
+	return &RingLog{lines: make([]string, size)}
+}
+
+// Write implements io.Writer, splitting p on newlines and appending each
+// complete line to the ring. A trailing partial line is buffered until the
+// next Write completes it.
+func (r *RingLog) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.buf = append(r.buf, p...)
+	for {
+		i := bytes.IndexByte(r.buf, '\n')
+		if i < 0 {
+			break
+		}
+		r.append(string(r.buf[:i]))
+		r.buf = r.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// append must be called with r.mu held.
+func (r *RingLog) append(line string) {
+	if len(r.lines) == 0 {
+		return
+	}
+	r.lines[r.next] = line
+	r.next = (r.next + 1) % len(r.lines)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Tail returns up to the last n lines written, oldest first. n == 0 returns
+// no lines; n < 0 returns every retained line.
+func (r *RingLog) Tail(n int) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if n == 0 {
+		return []string{}
+	}
+	var ordered []string
+	if r.full {
+		ordered = append(ordered, r.lines[r.next:]...)
+		ordered = append(ordered, r.lines[:r.next]...)
+	} else {
+		ordered = append(ordered, r.lines[:r.next]...)
+	}
+	if n < 0 || n >= len(ordered) {
+		return ordered
+	}
+	return ordered[len(ordered)-n:]
+}
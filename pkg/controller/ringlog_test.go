@@ -0,0 +1,91 @@
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestRingLogPartialLineBufferedAcrossWrites(t *testing.T) {
+	r := NewRingLog(10)
+	r.Write([]byte("hello "))
+	r.Write([]byte("world\nsecond"))
+	r.Write([]byte(" line\n"))
+
+	got := r.Tail(-1)
+	want := []string{"hello world", "second line"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Tail(-1) = %v, want %v", got, want)
+	}
+}
+
+func TestRingLogPartialLineNotFlushedUntilNewline(t *testing.T) {
+	r := NewRingLog(10)
+	r.Write([]byte("no newline yet"))
+	if got := r.Tail(-1); len(got) != 0 {
+		t.Fatalf("Tail(-1) = %v before any newline was written, want empty", got)
+	}
+	r.Write([]byte("\n"))
+	if got := r.Tail(-1); !reflect.DeepEqual(got, []string{"no newline yet"}) {
+		t.Fatalf("Tail(-1) = %v after the newline arrived, want [\"no newline yet\"]", got)
+	}
+}
+
+func TestRingLogTailWraparoundKeepsOnlyTheMostRecentLines(t *testing.T) {
+	r := NewRingLog(3)
+	for i := 1; i <= 5; i++ {
+		r.Write([]byte(fmt.Sprintf("line%d\n", i)))
+	}
+	got := r.Tail(-1)
+	want := []string{"line3", "line4", "line5"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Tail(-1) after wraparound = %v, want %v", got, want)
+	}
+}
+
+func TestRingLogTailZeroReturnsNoLines(t *testing.T) {
+	r := NewRingLog(5)
+	r.Write([]byte("a\nb\nc\n"))
+	got := r.Tail(0)
+	if len(got) != 0 {
+		t.Fatalf("Tail(0) = %v, want an empty slice", got)
+	}
+}
+
+func TestRingLogTailNegativeReturnsEverything(t *testing.T) {
+	r := NewRingLog(5)
+	r.Write([]byte("a\nb\n"))
+	got := r.Tail(-1)
+	want := []string{"a", "b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Tail(-1) = %v, want %v", got, want)
+	}
+}
+
+func TestRingLogTailNGreaterThanRetainedReturnsEverything(t *testing.T) {
+	r := NewRingLog(10)
+	r.Write([]byte("a\nb\n"))
+	got := r.Tail(100)
+	want := []string{"a", "b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Tail(100) = %v, want %v", got, want)
+	}
+}
+
+func TestRingLogTailNLessThanRetained(t *testing.T) {
+	r := NewRingLog(10)
+	r.Write([]byte("a\nb\nc\nd\n"))
+	got := r.Tail(2)
+	want := []string{"c", "d"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Tail(2) = %v, want %v", got, want)
+	}
+}
+
+func TestRingLogSizeZeroRetainsNoLines(t *testing.T) {
+	r := NewRingLog(0)
+	r.Write([]byte("a\nb\n"))
+	if got := r.Tail(-1); len(got) != 0 {
+		t.Fatalf("Tail(-1) on a zero-size RingLog = %v, want empty", got)
+	}
+}
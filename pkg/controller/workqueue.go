@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"sync"
+)
+
+// clusterWorkQueue is a FIFO queue of cluster keys, modeled on
+// client-go's workqueue: adding a name that is already queued or already
+// being processed coalesces into a single pending reconcile instead of
+// growing the queue, and a name that is re-added while it is being
+// processed is requeued once processing finishes. This guarantees at most
+// one reconcile per cluster runs at a time, and that a burst of
+// notifications for the same cluster never grows unbounded.
+type clusterWorkQueue struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	queue        []ClusterKey
+	queued       map[ClusterKey]bool
+	processing   map[ClusterKey]bool
+	dirty        map[ClusterKey]bool // re-added while processing; requeue on Done
+	shuttingDown bool
+
+	metrics *queueMetrics
+}
+
+func newClusterWorkQueue(metrics *queueMetrics) *clusterWorkQueue {
+	q := &clusterWorkQueue{
+		queued:     make(map[ClusterKey]bool),
+		processing: make(map[ClusterKey]bool),
+		dirty:      make(map[ClusterKey]bool),
+		metrics:    metrics,
+	}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// Add enqueues key for reconciliation, coalescing with any pending
+// or in-flight work for the same cluster.
+func (q *clusterWorkQueue) Add(key ClusterKey) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.shuttingDown {
+		return
+	}
+	if q.processing[key] {
+		q.dirty[key] = true
+		return
+	}
+	if q.queued[key] {
+		q.metrics.incCoalesced()
+		return
+	}
+	q.queued[key] = true
+	q.queue = append(q.queue, key)
+	q.metrics.setQueueDepth(len(q.queue))
+	q.cond.Signal()
+}
+
+// Get blocks until a cluster name is available to process, or the queue is
+// shut down. The caller must call Done with the same name once it has
+// finished processing it.
+func (q *clusterWorkQueue) Get() (key ClusterKey, shutdown bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.queue) == 0 && !q.shuttingDown {
+		q.cond.Wait()
+	}
+	if len(q.queue) == 0 {
+		return ClusterKey{}, true
+	}
+	key = q.queue[0]
+	q.queue = q.queue[1:]
+	q.metrics.setQueueDepth(len(q.queue))
+	delete(q.queued, key)
+	q.processing[key] = true
+	return key, false
+}
+
+// Done marks key as finished processing. If it was re-Added while
+// being processed, it is requeued so the latest state is reconciled too.
+func (q *clusterWorkQueue) Done(key ClusterKey) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.processing, key)
+	if q.dirty[key] {
+		delete(q.dirty, key)
+		q.queued[key] = true
+		q.queue = append(q.queue, key)
+		q.metrics.setQueueDepth(len(q.queue))
+		q.cond.Signal()
+	}
+}
+
+// ShutDown causes all blocked and future Get calls to return shutdown=true.
+func (q *clusterWorkQueue) ShutDown() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.shuttingDown = true
+	q.cond.Broadcast()
+}
@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClusterWorkQueueCoalescesDuplicateAdds(t *testing.T) {
+	q := newClusterWorkQueue(newQueueMetrics())
+	key := ClusterKey{Namespace: "teamA", Name: "prod"}
+
+	q.Add(key)
+	q.Add(key)
+	q.Add(key)
+
+	if got := q.metrics.Coalesced(); got != 2 {
+		t.Fatalf("Coalesced() = %d, want 2", got)
+	}
+
+	got, shutdown := q.Get()
+	if shutdown {
+		t.Fatalf("Get() reported shutdown on a fresh queue")
+	}
+	if got != key {
+		t.Fatalf("Get() = %v, want %v", got, key)
+	}
+	q.Done(got)
+
+	// Nothing else was queued: a second Get must block. Use a background
+	// goroutine with a timeout instead of calling Get on the test goroutine.
+	done := make(chan struct{})
+	go func() {
+		q.Get()
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("Get() returned immediately when the queue should have been empty")
+	case <-time.After(20 * time.Millisecond):
+	}
+	q.ShutDown()
+	<-done
+}
+
+func TestClusterWorkQueueRequeuesDirtyKeyAfterDone(t *testing.T) {
+	q := newClusterWorkQueue(newQueueMetrics())
+	key := ClusterKey{Namespace: "teamA", Name: "prod"}
+
+	q.Add(key)
+	got, _ := q.Get()
+	if got != key {
+		t.Fatalf("Get() = %v, want %v", got, key)
+	}
+
+	// Re-added while processing: must not grow the queue or coalesce, but
+	// must be requeued once Done is called.
+	q.Add(key)
+	if depth := q.metrics.QueueDepth(); depth != 0 {
+		t.Fatalf("QueueDepth() = %d while key was still processing, want 0", depth)
+	}
+
+	q.Done(key)
+	if depth := q.metrics.QueueDepth(); depth != 1 {
+		t.Fatalf("QueueDepth() = %d after Done requeued a dirty key, want 1", depth)
+	}
+
+	got, shutdown := q.Get()
+	if shutdown || got != key {
+		t.Fatalf("Get() = %v, %v, want %v, false", got, shutdown, key)
+	}
+}
+
+func TestClusterWorkQueueKeepsClustersIndependent(t *testing.T) {
+	q := newClusterWorkQueue(newQueueMetrics())
+	prodA := ClusterKey{Namespace: "teamA", Name: "prod"}
+	prodB := ClusterKey{Namespace: "teamB", Name: "prod"}
+
+	q.Add(prodA)
+	q.Add(prodB)
+
+	if got := q.metrics.Coalesced(); got != 0 {
+		t.Fatalf("Coalesced() = %d, want 0: same-named clusters in different namespaces must not coalesce", got)
+	}
+
+	seen := make(map[ClusterKey]bool)
+	for i := 0; i < 2; i++ {
+		key, shutdown := q.Get()
+		if shutdown {
+			t.Fatalf("Get() reported shutdown before both keys were returned")
+		}
+		seen[key] = true
+		q.Done(key)
+	}
+	if !seen[prodA] || !seen[prodB] {
+		t.Fatalf("Get() returned %v, want both %v and %v", seen, prodA, prodB)
+	}
+}
+
+func TestClusterWorkQueueShutDownUnblocksGet(t *testing.T) {
+	q := newClusterWorkQueue(newQueueMetrics())
+	done := make(chan bool, 1)
+	go func() {
+		_, shutdown := q.Get()
+		done <- shutdown
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("Get() returned before ShutDown on an empty queue")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	q.ShutDown()
+	select {
+	case shutdown := <-done:
+		if !shutdown {
+			t.Fatal("Get() after ShutDown reported shutdown=false")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Get() did not unblock after ShutDown")
+	}
+}
+
+func TestClusterWorkQueueAddAfterShutDownIsNoOp(t *testing.T) {
+	q := newClusterWorkQueue(newQueueMetrics())
+	q.ShutDown()
+	q.Add(ClusterKey{Namespace: "teamA", Name: "prod"})
+	if depth := q.metrics.QueueDepth(); depth != 0 {
+		t.Fatalf("QueueDepth() = %d after Add on a shut-down queue, want 0", depth)
+	}
+}